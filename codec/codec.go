@@ -0,0 +1,240 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package codec provides the scratch buffer that the gen package's fast
+// codec mode (Options.FastCodec / the (go.fastcodec) annotation) writes
+// generated Encode/Decode methods against, so that marshaling a struct
+// does not have to build an intermediate wire.Value tree.
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+)
+
+// ErrShortBuffer is returned by a Reader method when it runs out of bytes
+// before it can read a complete value.
+var ErrShortBuffer = errors.New("codec: buffer too short")
+
+// Writer accumulates a big-endian, length-prefixed encoding of Thrift
+// values into a single growable byte slice. A Writer is reusable: call
+// Reset (or obtain one from GetWriter/PutWriter) to encode another value without
+// allocating a new backing array.
+type Writer struct {
+	buf []byte
+}
+
+// NewWriter returns a Writer that writes into buf's backing array from its
+// start (buf may be nil); any bytes already in buf are discarded.
+func NewWriter(buf []byte) *Writer {
+	return &Writer{buf: buf[:0]}
+}
+
+// Reset discards any bytes written so far so the Writer can be reused.
+func (w *Writer) Reset() { w.buf = w.buf[:0] }
+
+// Bytes returns the bytes written so far. The slice is only valid until
+// the next call to Reset.
+func (w *Writer) Bytes() []byte { return w.buf }
+
+// WriteBool appends a single byte encoding v.
+func (w *Writer) WriteBool(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// WriteInt8 appends a single byte encoding v.
+func (w *Writer) WriteInt8(v int8) {
+	w.buf = append(w.buf, byte(v))
+}
+
+// WriteInt16 appends a fixed-size, big-endian encoding of v.
+func (w *Writer) WriteInt16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	w.buf = append(w.buf, b[:]...)
+}
+
+// WriteInt32 appends a fixed-size, big-endian encoding of v.
+func (w *Writer) WriteInt32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.buf = append(w.buf, b[:]...)
+}
+
+// WriteInt64 appends a fixed-size, big-endian encoding of v.
+func (w *Writer) WriteInt64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.buf = append(w.buf, b[:]...)
+}
+
+// WriteDouble appends a fixed-size, big-endian encoding of v.
+func (w *Writer) WriteDouble(v float64) {
+	w.WriteInt64(int64(math.Float64bits(v)))
+}
+
+// WriteString appends a length-prefixed encoding of s.
+func (w *Writer) WriteString(s string) {
+	w.WriteInt32(int32(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// WriteBinary appends a length-prefixed encoding of b.
+func (w *Writer) WriteBinary(b []byte) {
+	w.WriteInt32(int32(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// Reader reads the encoding produced by Writer back out, tracking its own
+// read offset into buf.
+type Reader struct {
+	buf []byte
+	off int
+}
+
+// NewReader returns a Reader over buf.
+func NewReader(buf []byte) *Reader {
+	return &Reader{buf: buf}
+}
+
+// Remaining returns the number of unread bytes left in the buffer. Callers
+// decoding a length prefix (a list/set/map count) should check it against
+// Remaining before using that length to size a preallocation, since the
+// prefix comes straight off the wire and a corrupt or malicious payload can
+// claim an arbitrarily large count.
+func (r *Reader) Remaining() int {
+	return len(r.buf) - r.off
+}
+
+func (r *Reader) take(n int) ([]byte, error) {
+	if len(r.buf)-r.off < n {
+		return nil, ErrShortBuffer
+	}
+	b := r.buf[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+// ReadBool reads a single byte written by WriteBool.
+func (r *Reader) ReadBool() (bool, error) {
+	b, err := r.take(1)
+	if err != nil {
+		return false, err
+	}
+	return b[0] != 0, nil
+}
+
+// ReadInt8 reads a single byte written by WriteInt8.
+func (r *Reader) ReadInt8() (int8, error) {
+	b, err := r.take(1)
+	if err != nil {
+		return 0, err
+	}
+	return int8(b[0]), nil
+}
+
+// ReadInt16 reads the fixed-size encoding written by WriteInt16.
+func (r *Reader) ReadInt16() (int16, error) {
+	b, err := r.take(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+// ReadInt32 reads the fixed-size encoding written by WriteInt32.
+func (r *Reader) ReadInt32() (int32, error) {
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+// ReadInt64 reads the fixed-size encoding written by WriteInt64.
+func (r *Reader) ReadInt64() (int64, error) {
+	b, err := r.take(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// ReadDouble reads the fixed-size encoding written by WriteDouble.
+func (r *Reader) ReadDouble() (float64, error) {
+	v, err := r.ReadInt64()
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(uint64(v)), nil
+}
+
+// ReadString reads the length-prefixed encoding written by WriteString.
+func (r *Reader) ReadString() (string, error) {
+	n, err := r.ReadInt32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.take(int(n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ReadBinary reads the length-prefixed encoding written by WriteBinary.
+func (r *Reader) ReadBinary() ([]byte, error) {
+	n, err := r.ReadInt32()
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.take(int(n))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out, nil
+}
+
+// writerPool lets generated Encode methods borrow a scratch Writer instead
+// of allocating one on every call.
+var writerPool = sync.Pool{
+	New: func() interface{} { return NewWriter(make([]byte, 0, 64)) },
+}
+
+// GetWriter returns a reset Writer from a shared pool. The caller must
+// return it with PutWriter once it is done reading the Writer's Bytes.
+func GetWriter() *Writer {
+	w := writerPool.Get().(*Writer)
+	w.Reset()
+	return w
+}
+
+// PutWriter returns w to the shared pool.
+func PutWriter(w *Writer) {
+	writerPool.Put(w)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package codec
+
+import "testing"
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	w := NewWriter(nil)
+	w.WriteBool(true)
+	w.WriteInt8(-8)
+	w.WriteInt16(-16)
+	w.WriteInt32(-32)
+	w.WriteInt64(-64)
+	w.WriteDouble(3.25)
+	w.WriteString("hello")
+	w.WriteBinary([]byte{1, 2, 3})
+
+	r := NewReader(w.Bytes())
+
+	if v, err := r.ReadBool(); err != nil || v != true {
+		t.Fatalf("ReadBool() = (%v, %v), want (true, nil)", v, err)
+	}
+	if v, err := r.ReadInt8(); err != nil || v != -8 {
+		t.Fatalf("ReadInt8() = (%v, %v), want (-8, nil)", v, err)
+	}
+	if v, err := r.ReadInt16(); err != nil || v != -16 {
+		t.Fatalf("ReadInt16() = (%v, %v), want (-16, nil)", v, err)
+	}
+	if v, err := r.ReadInt32(); err != nil || v != -32 {
+		t.Fatalf("ReadInt32() = (%v, %v), want (-32, nil)", v, err)
+	}
+	if v, err := r.ReadInt64(); err != nil || v != -64 {
+		t.Fatalf("ReadInt64() = (%v, %v), want (-64, nil)", v, err)
+	}
+	if v, err := r.ReadDouble(); err != nil || v != 3.25 {
+		t.Fatalf("ReadDouble() = (%v, %v), want (3.25, nil)", v, err)
+	}
+	if v, err := r.ReadString(); err != nil || v != "hello" {
+		t.Fatalf("ReadString() = (%q, %v), want (\"hello\", nil)", v, err)
+	}
+	if v, err := r.ReadBinary(); err != nil || string(v) != "\x01\x02\x03" {
+		t.Fatalf("ReadBinary() = (%v, %v), want ([1 2 3], nil)", v, err)
+	}
+}
+
+func TestReadShortBuffer(t *testing.T) {
+	r := NewReader([]byte{0})
+	if _, err := r.ReadInt32(); err != ErrShortBuffer {
+		t.Fatalf("ReadInt32() on a 1-byte buffer = %v, want ErrShortBuffer", err)
+	}
+}
+
+func TestGetWriterPutWriterReset(t *testing.T) {
+	w := GetWriter()
+	w.WriteString("leftover")
+	PutWriter(w)
+
+	w2 := GetWriter()
+	if len(w2.Bytes()) != 0 {
+		t.Fatalf("GetWriter() after PutWriter = %d leftover bytes, want a reset Writer", len(w2.Bytes()))
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"fmt"
+
+	"github.com/thriftrw/thriftrw-go/compile"
+)
+
+// fastCodecAnnotationKey is the Thrift annotation that opts a single struct
+// into the direct-to-wire codec path, e.g.
+//
+// 	struct Foo {
+// 		1: required string name
+// 	} (go.fastcodec)
+const fastCodecAnnotationKey = "go.fastcodec"
+
+// hasFastCodec returns true if the given TypeSpec should generate a direct
+// Encode/Decode pair that bypasses wire.Value, either because the caller
+// turned on Options.FastCodec globally, because the struct carries the
+// (go.fastcodec) annotation, or because its name was recorded by
+// markFastCodec (as DeclareFastCodec does).
+func (g *Generator) hasFastCodec(spec compile.TypeSpec) bool {
+	if g.Options.FastCodec {
+		return true
+	}
+
+	if s, ok := spec.(*compile.StructSpec); ok {
+		if _, ok := s.Annotations[fastCodecAnnotationKey]; ok {
+			return true
+		}
+	}
+
+	_, ok := g.fastCodecTypes[spec.ThriftName()]
+	return ok
+}
+
+// markFastCodec records that the named struct has (or will have) a fast
+// codec, so that container codecs generated for lists/sets/maps of that
+// struct can also take the fast path instead of falling back to wire.Value.
+func (g *Generator) markFastCodec(name string) {
+	if g.fastCodecTypes == nil {
+		g.fastCodecTypes = make(map[string]struct{})
+	}
+	g.fastCodecTypes[name] = struct{}{}
+}
+
+// encodeField returns Go source that writes the value referenced by the
+// expression "v" of the given TypeSpec into the *codec.Writer named "buf",
+// using fixed-size or length-prefixed encodings rather than building a
+// wire.Value. It is the fast-codec analog of toWire.
+func (g *Generator) encodeField(spec compile.TypeSpec, buf string, v string) (string, error) {
+	switch s := spec.(type) {
+	case *compile.BoolSpec:
+		return fmt.Sprintf("%s.WriteBool(%s)", buf, v), nil
+	case *compile.I8Spec:
+		return fmt.Sprintf("%s.WriteInt8(%s)", buf, v), nil
+	case *compile.I16Spec:
+		return fmt.Sprintf("%s.WriteInt16(%s)", buf, v), nil
+	case *compile.I32Spec:
+		return fmt.Sprintf("%s.WriteInt32(%s)", buf, v), nil
+	case *compile.I64Spec:
+		return fmt.Sprintf("%s.WriteInt64(%s)", buf, v), nil
+	case *compile.DoubleSpec:
+		return fmt.Sprintf("%s.WriteDouble(%s)", buf, v), nil
+	case *compile.StringSpec:
+		return fmt.Sprintf("%s.WriteString(%s)", buf, v), nil
+	case *compile.BinarySpec:
+		return fmt.Sprintf("%s.WriteBinary(%s)", buf, v), nil
+	case *compile.StructSpec:
+		if !g.hasFastCodec(s) {
+			return "", fmt.Errorf(
+				"cannot use fast codec for %q: struct does not have a fast codec", s.ThriftName(),
+			)
+		}
+		// Nested structs append straight into the same scratch buffer
+		// instead of going through the io.Writer-based Encode, so one
+		// Writer is reused for the whole object graph.
+		return fmt.Sprintf("%s.encodeFastCodec(%s)", v, buf), nil
+	case *compile.TypedefSpec:
+		return g.encodeField(s.Target, buf, v)
+	case *compile.ListSpec:
+		elemEncode, err := g.encodeField(s.ValueSpec, buf, "elem")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(
+			"%s.WriteInt32(int32(len(%s)))\n\tfor _, elem := range %s {\n\t\t%s\n\t}",
+			buf, v, v, elemEncode,
+		), nil
+	case *compile.SetSpec:
+		// Sets are represented as map[T]struct{}; only the keys carry data.
+		elemEncode, err := g.encodeField(s.ValueSpec, buf, "elem")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(
+			"%s.WriteInt32(int32(len(%s)))\n\tfor elem := range %s {\n\t\t%s\n\t}",
+			buf, v, v, elemEncode,
+		), nil
+	case *compile.MapSpec:
+		keyEncode, err := g.encodeField(s.KeySpec, buf, "mk")
+		if err != nil {
+			return "", err
+		}
+		valEncode, err := g.encodeField(s.ValueSpec, buf, "mv")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(
+			"%s.WriteInt32(int32(len(%s)))\n\tfor mk, mv := range %s {\n\t\t%s\n\t\t%s\n\t}",
+			buf, v, v, keyEncode, valEncode,
+		), nil
+	default:
+		return "", fmt.Errorf("fast codec does not support %T yet; add it or fall back to toWire", spec)
+	}
+}
+
+// decodeField is the fast-codec analog of fromWire: it returns a Go
+// expression of type (T, error) that reads a value of the given TypeSpec
+// out of the *codec.Reader named "r".
+func (g *Generator) decodeField(spec compile.TypeSpec, r string) (string, error) {
+	switch s := spec.(type) {
+	case *compile.BoolSpec:
+		return fmt.Sprintf("%s.ReadBool()", r), nil
+	case *compile.I8Spec:
+		return fmt.Sprintf("%s.ReadInt8()", r), nil
+	case *compile.I16Spec:
+		return fmt.Sprintf("%s.ReadInt16()", r), nil
+	case *compile.I32Spec:
+		return fmt.Sprintf("%s.ReadInt32()", r), nil
+	case *compile.I64Spec:
+		return fmt.Sprintf("%s.ReadInt64()", r), nil
+	case *compile.DoubleSpec:
+		return fmt.Sprintf("%s.ReadDouble()", r), nil
+	case *compile.StringSpec:
+		return fmt.Sprintf("%s.ReadString()", r), nil
+	case *compile.BinarySpec:
+		return fmt.Sprintf("%s.ReadBinary()", r), nil
+	case *compile.StructSpec:
+		if !g.hasFastCodec(s) {
+			return "", fmt.Errorf(
+				"cannot use fast codec for %q: struct does not have a fast codec", s.ThriftName(),
+			)
+		}
+		name := typeDeclName(s)
+		return fmt.Sprintf(
+			"func() (*%s, error) {\n\t\tresult := &%s{}\n\t\tif err := result.decodeFastCodec(%s); err != nil {\n\t\t\treturn nil, err\n\t\t}\n\t\treturn result, nil\n\t}()",
+			name, name, r,
+		), nil
+	case *compile.TypedefSpec:
+		return g.decodeField(s.Target, r)
+	case *compile.ListSpec:
+		elemType := typeReference(s.ValueSpec, Required)
+		elemDecode, err := g.decodeField(s.ValueSpec, r)
+		if err != nil {
+			return "", err
+		}
+		codecAlias := g.Import("github.com/thriftrw/thriftrw-go/codec")
+		return fmt.Sprintf(
+			"func() ([]%s, error) {\n\t\tn, err := %s.ReadInt32()\n\t\tif err != nil {\n\t\t\treturn nil, err\n\t\t}\n"+
+				"\t\tif n < 0 || int(n) > %s.Remaining() {\n\t\t\treturn nil, %s.ErrShortBuffer\n\t\t}\n"+
+				"\t\tresult := make([]%s, 0, n)\n\t\tfor i := int32(0); i < n; i++ {\n\t\t\telem, err := %s\n"+
+				"\t\t\tif err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n\t\t\tresult = append(result, elem)\n\t\t}\n"+
+				"\t\treturn result, nil\n\t}()",
+			elemType, r, r, codecAlias, elemType, elemDecode,
+		), nil
+	case *compile.SetSpec:
+		elemType := typeReference(s.ValueSpec, Required)
+		elemDecode, err := g.decodeField(s.ValueSpec, r)
+		if err != nil {
+			return "", err
+		}
+		codecAlias := g.Import("github.com/thriftrw/thriftrw-go/codec")
+		return fmt.Sprintf(
+			"func() (map[%s]struct{}, error) {\n\t\tn, err := %s.ReadInt32()\n\t\tif err != nil {\n\t\t\treturn nil, err\n\t\t}\n"+
+				"\t\tif n < 0 || int(n) > %s.Remaining() {\n\t\t\treturn nil, %s.ErrShortBuffer\n\t\t}\n"+
+				"\t\tresult := make(map[%s]struct{}, n)\n\t\tfor i := int32(0); i < n; i++ {\n\t\t\telem, err := %s\n"+
+				"\t\t\tif err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n\t\t\tresult[elem] = struct{}{}\n\t\t}\n"+
+				"\t\treturn result, nil\n\t}()",
+			elemType, r, r, codecAlias, elemType, elemDecode,
+		), nil
+	case *compile.MapSpec:
+		keyType := typeReference(s.KeySpec, Required)
+		valType := typeReference(s.ValueSpec, Required)
+		keyDecode, err := g.decodeField(s.KeySpec, r)
+		if err != nil {
+			return "", err
+		}
+		valDecode, err := g.decodeField(s.ValueSpec, r)
+		if err != nil {
+			return "", err
+		}
+		codecAlias := g.Import("github.com/thriftrw/thriftrw-go/codec")
+		return fmt.Sprintf(
+			"func() (map[%s]%s, error) {\n\t\tn, err := %s.ReadInt32()\n\t\tif err != nil {\n\t\t\treturn nil, err\n\t\t}\n"+
+				"\t\tif n < 0 || int(n) > %s.Remaining() {\n\t\t\treturn nil, %s.ErrShortBuffer\n\t\t}\n"+
+				"\t\tresult := make(map[%s]%s, n)\n\t\tfor i := int32(0); i < n; i++ {\n\t\t\tmk, err := %s\n"+
+				"\t\t\tif err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n\t\t\tmv, err := %s\n"+
+				"\t\t\tif err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n\t\t\tresult[mk] = mv\n\t\t}\n"+
+				"\t\treturn result, nil\n\t}()",
+			keyType, valType, r, r, codecAlias, keyType, valType, keyDecode, valDecode,
+		), nil
+	default:
+		return "", fmt.Errorf("fast codec does not support %T yet; add it or fall back to fromWire", spec)
+	}
+}
+
+// fastCodecData is the template context for generating one struct's fast
+// Encode/Decode pair.
+type fastCodecData struct {
+	Spec *compile.StructSpec
+}
+
+// fastCodecTemplate declares the unexported encodeFastCodec/decodeFastCodec
+// helpers (which read and write a shared, reusable *codec.Writer/Reader so
+// that nested structs don't each allocate their own) plus the public,
+// io-based Encode/Decode pair the request asked for.
+const fastCodecTemplate = `
+func (v *<defName .Spec>) encodeFastCodec(buf *<$codec := import "github.com/thriftrw/thriftrw-go/codec"><$codec>.Writer) {
+<range .Spec.Fields>	<encodeField .Type "buf" (print "v." (goCase .Name))>
+</range>}
+
+func (v *<defName .Spec>) decodeFastCodec(r *<$codec>.Reader) error {
+<range .Spec.Fields>	<$val := newVar (goCase .Name)><$val>, err := <decodeField .Type "r">
+	if err != nil {
+		return err
+	}
+	v.<goCase .Name> = <$val>
+</range>	return nil
+}
+
+// Encode writes v to w without building an intermediate wire.Value tree.
+func (v *<defName .Spec>) Encode(w <$io := import "io"><$io>.Writer) error {
+	buf := <$codec>.GetWriter()
+	defer <$codec>.PutWriter(buf)
+
+	v.encodeFastCodec(buf)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Decode reads v back out of r, as written by Encode.
+func (v *<defName .Spec>) Decode(r <$io>.Reader) error {
+	<$ioutil := import "io/ioutil">bs, err := <$ioutil>.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return v.decodeFastCodec(<$codec>.NewReader(bs))
+}
+`
+
+// DeclareFastCodec generates the Encode/Decode pair for the given struct,
+// bypassing wire.Value. It records the struct as fast-codec-enabled before
+// rendering its fields, so self-referential structs and container fields
+// (lists/sets/maps of this struct) correctly take the fast path too.
+//
+// Callers are responsible for checking hasFastCodec (Options.FastCodec or
+// the (go.fastcodec) annotation) before calling DeclareFastCodec; it does
+// not check this itself, since by the time it is called the struct has
+// already been chosen to get a fast codec.
+func (g *Generator) DeclareFastCodec(spec *compile.StructSpec) error {
+	g.markFastCodec(spec.ThriftName())
+
+	data := fastCodecData{Spec: spec}
+	return g.DeclareFromTemplateName(GroupTypes, nil, "fastcodec", fastCodecTemplate, data)
+}
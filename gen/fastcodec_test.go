@@ -0,0 +1,101 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thriftrw/thriftrw-go/compile"
+)
+
+func TestEncodeDecodeFieldList(t *testing.T) {
+	g := NewGenerator()
+	list := &compile.ListSpec{ValueSpec: &compile.StringSpec{}}
+
+	encoded, err := g.encodeField(list, "buf", "v.Names")
+	if err != nil {
+		t.Fatalf("encodeField(list of string) = %v, want nil error", err)
+	}
+	if !strings.Contains(encoded, "buf.WriteInt32") || !strings.Contains(encoded, "buf.WriteString(elem)") {
+		t.Fatalf("encodeField(list of string) = %q, want a length prefix and a per-element WriteString", encoded)
+	}
+
+	decoded, err := g.decodeField(list, "r")
+	if err != nil {
+		t.Fatalf("decodeField(list of string) = %v, want nil error", err)
+	}
+	if !strings.Contains(decoded, "[]string") || !strings.Contains(decoded, "r.ReadString()") {
+		t.Fatalf("decodeField(list of string) = %q, want a []string result built from r.ReadString()", decoded)
+	}
+}
+
+func TestEncodeDecodeFieldMap(t *testing.T) {
+	g := NewGenerator()
+	m := &compile.MapSpec{KeySpec: &compile.StringSpec{}, ValueSpec: &compile.I32Spec{}}
+
+	encoded, err := g.encodeField(m, "buf", "v.Counts")
+	if err != nil {
+		t.Fatalf("encodeField(map<string,i32>) = %v, want nil error", err)
+	}
+	if !strings.Contains(encoded, "buf.WriteString(mk)") || !strings.Contains(encoded, "buf.WriteInt32(mv)") {
+		t.Fatalf("encodeField(map<string,i32>) = %q, want both key and value writes", encoded)
+	}
+
+	decoded, err := g.decodeField(m, "r")
+	if err != nil {
+		t.Fatalf("decodeField(map<string,i32>) = %v, want nil error", err)
+	}
+	if !strings.Contains(decoded, "map[string]int32") {
+		t.Fatalf("decodeField(map<string,i32>) = %q, want a map[string]int32 result", decoded)
+	}
+}
+
+func TestDeclareFastCodecMarksStruct(t *testing.T) {
+	g := NewGenerator()
+	spec := &compile.StructSpec{
+		Name: "Foo",
+		Fields: []*compile.FieldSpec{
+			{Name: "Name", Type: &compile.StringSpec{}},
+		},
+	}
+
+	if g.hasFastCodec(spec) {
+		t.Fatal("hasFastCodec(Foo) = true before DeclareFastCodec, want false")
+	}
+
+	if err := g.DeclareFastCodec(spec); err != nil {
+		t.Fatalf("DeclareFastCodec(Foo) = %v, want nil", err)
+	}
+
+	if !g.hasFastCodec(spec) {
+		t.Fatal("hasFastCodec(Foo) = false after DeclareFastCodec, want true: markFastCodec should have run")
+	}
+}
+
+func TestEncodeFieldRejectsStructWithoutFastCodec(t *testing.T) {
+	g := NewGenerator()
+	other := &compile.StructSpec{Name: "Bar"}
+
+	if _, err := g.encodeField(other, "buf", "v.Bar"); err == nil {
+		t.Fatal("encodeField(struct without fast codec) = nil error, want an error")
+	}
+}
@@ -24,19 +24,58 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
-	"go/format"
 	"go/parser"
 	"go/token"
-	"io"
 	"text/template"
 )
 
+// Options controls optional, opt-in behaviors of the Generator that are not
+// determined solely by the Thrift source being compiled.
+type Options struct {
+	// FastCodec, when true, makes every struct count as having a fast
+	// codec (see hasFastCodec), so DeclareFastCodec can be used for any
+	// struct and encodeField/decodeField accept fields of any struct type.
+	// Individual structs may also opt in with the (go.fastcodec) annotation
+	// even when this is false. The Generator does not call DeclareFastCodec
+	// on its own; setting this flag alone declares no Encode/Decode pair.
+	FastCodec bool
+
+	// TemplateOverrides, if non-empty, names a directory of "*.tmpl" files
+	// whose base names (minus the extension) match one of the templates
+	// built into this package, e.g. "fastcodec.tmpl" replaces the built-in
+	// "fastcodec" template. Overrides can call any function in
+	// Generator.FuncMap, including toWire, fromWire, and typeReference.
+	TemplateOverrides string
+
+	// ReservedIdentifiers adds to the built-in set of names (receivers,
+	// loop variables, template helper names) that Reserve and NewName
+	// refuse to hand out to a Thrift-declared symbol.
+	ReservedIdentifiers []string
+}
+
 // Generator tracks code generation state as we generate the output.
 type Generator struct {
-	importer
 	*namespace
 
-	decls []ast.Decl
+	// Options holds the user-configurable behaviors for this Generator.
+	// It may be changed any time before code generation begins.
+	Options Options
+
+	// PackageName is used as the package clause of every emitted file.
+	PackageName string
+
+	// files holds one fileBuilder per output file, keyed by group name.
+	// currentFile is the fileBuilder that DeclareFromTemplate is currently
+	// appending to.
+	files       map[string]*fileBuilder
+	currentFile *fileBuilder
+
+	// templates is lazily populated by TemplateSet from Options.TemplateOverrides.
+	templates *TemplateSet
+
+	// reservedIdentsApplied guards against adding Options.ReservedIdentifiers
+	// to the namespace more than once.
+	reservedIdentsApplied bool
 
 	listValueLists map[string]struct{}
 	setValueLists  map[string]struct{}
@@ -46,51 +85,60 @@ type Generator struct {
 	setReaders  map[string]struct{}
 	mapReaders  map[string]struct{}
 
-	// TODO use something to group related decls together
+	// fastCodecTypes tracks the names of structs that have a fast codec
+	// (either via Options.FastCodec or a per-struct annotation) so that
+	// container codecs generated for lists/sets/maps of those types can
+	// also take the fast path.
+	fastCodecTypes map[string]struct{}
 }
 
 // NewGenerator sets up a new generator for Go code.
 func NewGenerator() *Generator {
 	namespace := newNamespace()
-	return &Generator{
+	g := &Generator{
 		namespace:      namespace,
-		importer:       newImporter(namespace),
+		PackageName:    "thriftrw",
+		files:          make(map[string]*fileBuilder),
 		listValueLists: make(map[string]struct{}),
 		listReaders:    make(map[string]struct{}),
 		setValueLists:  make(map[string]struct{}),
 		setReaders:     make(map[string]struct{}),
 		mapItemLists:   make(map[string]struct{}),
 		mapReaders:     make(map[string]struct{}),
+		fastCodecTypes: make(map[string]struct{}),
 	}
+	g.currentFile = g.fileBuilderFor(GroupTypes)
+	return g
 }
 
-// TextTemplate renders the given template with the given template context.
-func (g *Generator) TextTemplate(s string, data interface{}) (string, error) {
-	templateFuncs := template.FuncMap{
-		"goCase":          goCase,
-		"import":          g.Import,
-		"defName":         typeDeclName,
-		"newVar":          g.namespace.Child().NewName,
-		"toWire":          g.toWire,
-		"fromWire":        g.fromWire,
-		"typeName":        typeName,
-		"typeCode":        g.typeCode,
-		"typeReference":   typeReference,
-		"isStructType":    isStructType,
-		"isReferenceType": isReferenceType,
-
-		"Required": func() fieldRequired { return Required },
-		"Optional": func() fieldRequired { return Optional },
-		"required": func(b bool) fieldRequired {
-			if b {
-				return Required
-			}
-			return Optional
-		},
+// fileBuilderFor returns the fileBuilder for the given group, creating it
+// (and importing its own independent set of imports) if this is the first
+// declaration routed to that group.
+func (g *Generator) fileBuilderFor(group string) *fileBuilder {
+	fb, ok := g.files[group]
+	if !ok {
+		fb = newFileBuilder(g.namespace)
+		g.files[group] = fb
 	}
+	return fb
+}
+
+// Import returns the name that should be used to refer to the given
+// imported package from the file currently being generated.
+func (g *Generator) Import(path string) string {
+	return g.currentFile.Import(path)
+}
+
+// AddImportSpec registers an explicit import (as parsed from a template's
+// output) against the file currently being generated.
+func (g *Generator) AddImportSpec(spec *ast.ImportSpec) error {
+	return g.currentFile.AddImportSpec(spec)
+}
 
+// TextTemplate renders the given template with the given template context.
+func (g *Generator) TextTemplate(s string, data interface{}) (string, error) {
 	tmpl, err := template.New("thriftrw").
-		Delims("<", ">").Funcs(templateFuncs).Parse(s)
+		Delims("<", ">").Funcs(template.FuncMap(g.FuncMap())).Parse(s)
 	if err != nil {
 		return "", err
 	}
@@ -104,19 +152,6 @@ func (g *Generator) TextTemplate(s string, data interface{}) (string, error) {
 
 }
 
-func (g *Generator) renderTemplate(s string, data interface{}) ([]byte, error) {
-	buff := bytes.NewBufferString("package thriftrw\n\n")
-	out, err := g.TextTemplate(s, data)
-	if err != nil {
-		return nil, err
-	}
-	if _, err := buff.WriteString(out); err != nil {
-		return nil, err
-	}
-
-	return buff.Bytes(), nil
-}
-
 func (g *Generator) recordGenDeclNames(d *ast.GenDecl) error {
 	switch d.Tok {
 	case token.IMPORT:
@@ -169,6 +204,8 @@ func (g *Generator) recordGenDeclNames(d *ast.GenDecl) error {
 // For example,
 //
 // 	g.DeclareFromTemplate(
+// 		gen.GroupTypes,
+// 		nil, // no source position to record
 // 		'type <.Name> int32',
 // 		struct{Name string}{Name: "myType"}
 // 	)
@@ -191,9 +228,13 @@ func (g *Generator) recordGenDeclNames(d *ast.GenDecl) error {
 // 	<$fmt>.Println("hello world")
 //
 // newVar(s): Gets a new name that the template can use for a variable without
-// worrying about shadowing any globals. Prefers the given string.
+// worrying about shadowing any globals. Prefers the given string, but falls
+// back to a suffixed variant if the string is already taken or is one of the
+// reserved identifiers that Reserve/NewName never hand out (see badIdents in
+// namespace.go and Options.ReservedIdentifiers) -- so, for example, plain
+// "x" is never actually returned; expect something like "x1" instead.
 //
-// 	<$x := newVar "x">
+// 	<$item := newVar "item">
 //
 // defName(TypeSpec): Takes a TypeSpec representing a **user declared type** and
 // returns the name that should be used in the Go code to define that type.
@@ -218,17 +259,44 @@ func (g *Generator) recordGenDeclNames(d *ast.GenDecl) error {
 //
 // fromWire(TypeSpec, v): Returns an expression of type (T, error) where T is
 // the type represented by TypeSpec, read from the given Value v.
-func (g *Generator) DeclareFromTemplate(s string, data interface{}) error {
-	bs, err := g.renderTemplate(s, data)
+//
+// group names the output file (without its ".go" suffix) that the
+// declarations produced by this template should be routed into, e.g.
+// GroupTypes, GroupConstants, GroupEnums, a service name, or, for large
+// modules, the name of the struct being declared. Imports referenced by the
+// template (directly or through helpers like toWire and typeCode) are
+// tracked against that same file.
+//
+// pos, if non-nil, is the Thrift source location that produced this
+// template's output. It is recorded as a leading "// Generated from ..."
+// comment on every top-level decl the template produces (see DeclPosition)
+// and, in WriteRaw mode, as a //line compiler directive so that a compile
+// error deep inside generated code points back at the .thrift file. Pass
+// nil when there is no single meaningful source location, e.g. for
+// synthetic helpers the generator emits on its own.
+func (g *Generator) DeclareFromTemplate(group string, pos *DeclPosition, s string, data interface{}) error {
+	if !g.reservedIdentsApplied {
+		g.namespace.addReservedIdentifiers(g.Options.ReservedIdentifiers)
+		g.reservedIdentsApplied = true
+	}
+
+	fb := g.fileBuilderFor(group)
+	g.currentFile = fb
+
+	out, err := g.TextTemplate(s, data)
 	if err != nil {
 		return err
 	}
 
-	f, err := parser.ParseFile(token.NewFileSet(), "thriftrw.go", bs, 0)
+	bs := []byte("package thriftrw\n\n" + out)
+
+	f, err := parser.ParseFile(token.NewFileSet(), "thriftrw.go", bs, parser.ParseComments)
 	if err != nil {
 		return fmt.Errorf("could not parse generated code: %v:\n%s", err, bs)
 	}
 
+	fb.raw = append(fb.raw, rawSection{pos: pos, text: out})
+
 	for _, decl := range f.Decls {
 		switch d := decl.(type) {
 		case *ast.FuncDecl:
@@ -238,41 +306,19 @@ func (g *Generator) DeclareFromTemplate(s string, data interface{}) error {
 					return err
 				}
 			}
+			setDeclDoc(d, pos)
 		case *ast.GenDecl:
 			if err := g.recordGenDeclNames(d); err != nil {
 				return err
 			}
+			if d.Tok != token.IMPORT {
+				setDeclDoc(d, pos)
+			}
 		default:
 			// No special behavior. Move along.
 		}
-		g.appendDecl(decl)
+		fb.decls = append(fb.decls, decl)
 	}
 
 	return nil
 }
-
-// TODO multiple modules
-
-func (g *Generator) Write(w io.Writer, fs *token.FileSet) error {
-	// TODO newlines between decls
-	// TODO constants first, types next, and functions after that
-	// TODO sorting
-
-	decls := make([]ast.Decl, 0, 1+len(g.decls))
-	importDecl := g.importDecl()
-	if importDecl != nil {
-		decls = append(decls, importDecl)
-	}
-	decls = append(decls, g.decls...)
-
-	file := &ast.File{
-		Decls: decls,
-		Name:  ast.NewIdent("todo"), // TODO
-	}
-	return format.Node(w, fs, file)
-}
-
-// appendDecl appends a new declaration to the generator.
-func (g *Generator) appendDecl(decl ast.Decl) {
-	g.decls = append(g.decls, decl)
-}
@@ -0,0 +1,183 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"go/ast"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Well-known file groups used by the built-in templates. Generator.Files
+// may contain other groups too, e.g. one per service or, for large
+// modules, one per top-level struct.
+const (
+	// GroupConstants is the file that holds Thrift constants.
+	GroupConstants = "constants"
+
+	// GroupTypes is the file that holds typedefs, structs, and unions that
+	// were not split out into their own file.
+	GroupTypes = "types"
+
+	// GroupEnums is the file that holds generated enums.
+	GroupEnums = "enums"
+)
+
+// fileBuilder accumulates the declarations and imports that belong in a
+// single generated file.
+type fileBuilder struct {
+	importer
+	decls []ast.Decl
+
+	// raw holds, in call order, the unformatted text rendered by each
+	// DeclareFromTemplate call routed to this file, alongside the source
+	// position that produced it. WriteRaw uses this instead of re-printing
+	// the parsed AST so that //line directives line up with real output.
+	raw []rawSection
+}
+
+func newFileBuilder(namespace *namespace) *fileBuilder {
+	return &fileBuilder{importer: newImporter(namespace)}
+}
+
+// declGroup is used to order declarations deterministically within a file:
+// constants first, then types, then everything else (mostly funcs), each
+// group sorted by name.
+type declGroup int
+
+const (
+	declGroupConst declGroup = iota
+	declGroupType
+	declGroupOther
+)
+
+func declSortKey(d ast.Decl) (declGroup, string) {
+	gd, ok := d.(*ast.GenDecl)
+	if !ok {
+		fd := d.(*ast.FuncDecl)
+		name := fd.Name.Name
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			name = typeReferenceExprName(fd.Recv.List[0].Type) + "." + name
+		}
+		return declGroupOther, name
+	}
+
+	switch gd.Tok {
+	case token.CONST:
+		return declGroupConst, genDeclSortName(gd)
+	case token.TYPE:
+		return declGroupType, genDeclSortName(gd)
+	default:
+		return declGroupOther, genDeclSortName(gd)
+	}
+}
+
+func genDeclSortName(gd *ast.GenDecl) string {
+	if len(gd.Specs) == 0 {
+		return ""
+	}
+	switch spec := gd.Specs[0].(type) {
+	case *ast.TypeSpec:
+		return spec.Name.Name
+	case *ast.ValueSpec:
+		if len(spec.Names) > 0 {
+			return spec.Names[0].Name
+		}
+	}
+	return ""
+}
+
+func typeReferenceExprName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return typeReferenceExprName(e.X)
+	case *ast.Ident:
+		return e.Name
+	default:
+		return ""
+	}
+}
+
+// sortDecls orders declarations the way Files/WriteFiles emit them:
+// constants, then types, then funcs, each sorted by name so that output is
+// stable across runs of the generator.
+func sortDecls(decls []ast.Decl) {
+	sort.SliceStable(decls, func(i, j int) bool {
+		gi, ni := declSortKey(decls[i])
+		gj, nj := declSortKey(decls[j])
+		if gi != gj {
+			return gi < gj
+		}
+		return ni < nj
+	})
+}
+
+// Files returns the full set of generated files, keyed by group name (no
+// ".go" suffix). Every Generator starts with at least GroupTypes; other
+// groups appear on demand as DeclareFromTemplate is called with their name.
+func (g *Generator) Files() (map[string]*ast.File, error) {
+	result := make(map[string]*ast.File, len(g.files))
+	for group, fb := range g.files {
+		decls := make([]ast.Decl, 0, len(fb.decls)+1)
+		if importDecl := fb.importDecl(); importDecl != nil {
+			decls = append(decls, importDecl)
+		}
+		sortedDecls := append([]ast.Decl(nil), fb.decls...)
+		sortDecls(sortedDecls)
+		decls = append(decls, sortedDecls...)
+
+		result[group] = &ast.File{
+			Decls: decls,
+			Name:  ast.NewIdent(g.PackageName),
+		}
+	}
+	return result, nil
+}
+
+// WriteFiles renders every file returned by Files into "<dir>/<group>.go".
+// The directory must already exist.
+func (g *Generator) WriteFiles(dir string) error {
+	files, err := g.Files()
+	if err != nil {
+		return err
+	}
+
+	for group, file := range files {
+		path := filepath.Join(dir, group+".go")
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+
+		err = format.Node(f, token.NewFileSet(), file)
+		closeErr := f.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import "fmt"
+
+// badIdents are names that a namespace refuses to hand out or reserve,
+// even if nothing has claimed them yet: common receivers, loop variables,
+// and names used by the template helpers themselves. Handing one of these
+// to a Thrift-named symbol (a field literally called "err", a method
+// called "String") would otherwise silently shadow a helper or produce
+// code that doesn't compile.
+var badIdents = map[string]struct{}{
+	"v":   {},
+	"err": {},
+	"buf": {},
+	"w":   {},
+	"r":   {},
+	"i":   {},
+	"j":   {},
+	"k":   {},
+	"x":   {},
+	"ok":  {},
+
+	// String and Error are methods every Go value effectively advertises
+	// to fmt/error-handling code (fmt.Stringer, the error interface); a
+	// Thrift method or field named "String" or "Error" would otherwise
+	// silently collide with -- or be mistaken for -- one of those.
+	"String": {},
+	"Error":  {},
+}
+
+// namespace tracks which identifiers have already been claimed in some
+// scope, so that Reserve and NewName never hand out the same name twice.
+// Namespaces nest: a Child namespace sees everything its parent has
+// claimed, but claims made in the child are invisible to the parent.
+type namespace struct {
+	parent *namespace
+	used   map[string]struct{}
+
+	// extraBadIdents holds identifiers added via
+	// Options.ReservedIdentifiers. It is only ever set on the root
+	// namespace; children consult their root.
+	extraBadIdents map[string]struct{}
+}
+
+// newNamespace creates a new, empty root namespace.
+func newNamespace() *namespace {
+	return &namespace{used: make(map[string]struct{})}
+}
+
+// Child returns a new namespace that inherits this namespace's claimed
+// and reserved names, but whose own claims do not leak back out.
+func (n *namespace) Child() *namespace {
+	return &namespace{parent: n, used: make(map[string]struct{})}
+}
+
+func (n *namespace) root() *namespace {
+	if n.parent != nil {
+		return n.parent.root()
+	}
+	return n
+}
+
+// addReservedIdentifiers extends the set of names that Reserve and NewName
+// will always refuse, on top of the built-in badIdents. It is used to
+// apply Options.ReservedIdentifiers.
+func (n *namespace) addReservedIdentifiers(names []string) {
+	root := n.root()
+	if root.extraBadIdents == nil {
+		root.extraBadIdents = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		root.extraBadIdents[name] = struct{}{}
+	}
+}
+
+func (n *namespace) isReserved(name string) bool {
+	if _, ok := badIdents[name]; ok {
+		return true
+	}
+	_, ok := n.root().extraBadIdents[name]
+	return ok
+}
+
+func (n *namespace) isUsed(name string) bool {
+	if _, ok := n.used[name]; ok {
+		return true
+	}
+	if n.parent != nil {
+		return n.parent.isUsed(name)
+	}
+	return false
+}
+
+// Reserve claims the given name in this namespace. It fails if the name
+// is already claimed, or if it is a reserved identifier (see badIdents and
+// Options.ReservedIdentifiers) -- reservation is for names that come
+// straight from a Thrift declaration, so the caller should report this
+// error pointing at the offending Thrift symbol rather than rename around
+// it.
+func (n *namespace) Reserve(name string) error {
+	if n.isReserved(name) {
+		return fmt.Errorf(
+			"cannot use %q: it is a reserved identifier in generated code", name,
+		)
+	}
+	if n.isUsed(name) {
+		return fmt.Errorf("cannot use %q: name already taken", name)
+	}
+	n.used[name] = struct{}{}
+	return nil
+}
+
+// NewName returns a name that is safe to use for a template-local helper
+// variable, preferring the given string but appending a numeric suffix
+// until it finds one that is neither taken nor reserved.
+func (n *namespace) NewName(s string) string {
+	name := s
+	for i := 1; n.isUsed(name) || n.isReserved(name); i++ {
+		name = fmt.Sprintf("%s%d", s, i)
+	}
+	n.used[name] = struct{}{}
+	return name
+}
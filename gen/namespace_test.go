@@ -0,0 +1,67 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import "testing"
+
+func TestNamespaceReserveRejectsBadIdents(t *testing.T) {
+	n := newNamespace()
+	for _, bad := range []string{"err", "buf", "v", "String", "Error"} {
+		if err := n.Reserve(bad); err == nil {
+			t.Errorf("Reserve(%q) = nil, want error because it is a reserved identifier", bad)
+		}
+	}
+}
+
+func TestNamespaceReserveAllowsOrdinaryNamesOnce(t *testing.T) {
+	n := newNamespace()
+	if err := n.Reserve("MyStruct"); err != nil {
+		t.Fatalf("Reserve(%q) = %v, want nil", "MyStruct", err)
+	}
+	if err := n.Reserve("MyStruct"); err == nil {
+		t.Fatalf("second Reserve(%q) = nil, want error for an already-claimed name", "MyStruct")
+	}
+}
+
+func TestNamespaceNewNameAvoidsBadIdents(t *testing.T) {
+	n := newNamespace()
+	if got := n.NewName("x"); got == "x" {
+		t.Fatalf("NewName(%q) = %q, want a suffixed name since %q is a reserved identifier", "x", got, "x")
+	}
+}
+
+func TestNamespaceAddReservedIdentifiers(t *testing.T) {
+	n := newNamespace()
+	n.addReservedIdentifiers([]string{"MySpecial"})
+	if err := n.Reserve("MySpecial"); err == nil {
+		t.Fatalf("Reserve(%q) = nil after addReservedIdentifiers, want error", "MySpecial")
+	}
+}
+
+func TestGeneratorAppliesOptionsReservedIdentifiers(t *testing.T) {
+	g := NewGenerator()
+	g.Options.ReservedIdentifiers = []string{"Frobnicate"}
+
+	err := g.DeclareFromTemplate(GroupTypes, nil, "type Frobnicate int32", nil)
+	if err == nil {
+		t.Fatal("DeclareFromTemplate declaring a reserved type name = nil error, want error")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"fmt"
+
+	"github.com/thriftrw/thriftrw-go/compile"
+)
+
+// serviceMethodData is the template context for a single Thrift method
+// when generating its shim Args/Result structs and registry entry.
+type serviceMethodData struct {
+	Service  *compile.ServiceSpec
+	Function *compile.FunctionSpec
+}
+
+// shimTemplate declares, for one Thrift method, the Args and Result shim
+// structs, a handler function type servers implement against, and a
+// register<Prefix> function that registers the method's codecs with the
+// shim registry. It deliberately does not declare its own func init(): Go
+// only special-cases *parsing* multiple init funcs per package, and
+// namespace.Reserve (rightly) refuses to hand out the same top-level name
+// "init" twice, so a service with more than one method needs a single
+// combined init -- see serviceInitTemplate below.
+const shimTemplate = `
+<$service := .Service.Name>
+<$method := .Function.Name>
+<$prefix := print (goCase $service) (goCase $method)>
+
+type <$prefix>Args struct {
+<range .Function.ArgsSpec>	<goCase .Name> <typeReference .Type (required .Required)> ` + "`json:\"<.Name>,omitempty\"`" + `
+</range>}
+
+type <$prefix>Result struct {
+<if .Function.ResultSpec.ReturnType>	Success <typeReference .Function.ResultSpec.ReturnType Optional>
+</if><range .Function.ResultSpec.Exceptions>	<goCase .Name> <typeReference .Type Optional>
+</range>}
+
+// <$prefix>Handler is implemented by servers of the <$service>.<$method>
+// Thrift method. It receives the decoded arguments and returns the
+// decoded result, without going through wire.Value or reflect at the
+// call site.
+type <$prefix>Handler func(ctx <$ctx := import "context"><$ctx>.Context, args *<$prefix>Args) (*<$prefix>Result, error)
+
+func register<$prefix>() {
+	<$shim := import "github.com/thriftrw/thriftrw-go/shim">
+	<$wire := import "github.com/thriftrw/thriftrw-go/wire">
+
+	<$shim>.RegisterMethod("<$service>", "<$method>",
+		func(v interface{}) (<$wire>.Value, error) {
+			return v.(*<$prefix>Args).ToWire()
+		},
+		func(v <$wire>.Value) (interface{}, error) {
+			return <$prefix>ArgsFromWire(v)
+		},
+		func(v interface{}) (<$wire>.Value, error) {
+			return v.(*<$prefix>Result).ToWire()
+		},
+		func(v <$wire>.Value) (interface{}, error) {
+			return <$prefix>ResultFromWire(v)
+		},
+	)
+}
+`
+
+// serviceInitData is the template context for the single combined init()
+// emitted once per service, after all of its per-method register funcs
+// have been declared.
+type serviceInitData struct {
+	RegisterFuncs []string
+}
+
+// serviceInitTemplate declares the one func init() a generated service
+// file is allowed to have, calling every method's register func in turn.
+const serviceInitTemplate = `
+func init() {
+<range .RegisterFuncs>	<.>()
+</range>}
+`
+
+// DeclareService generates, for every method on the given service, typed
+// Args/Result shim structs plus a func init() that registers per-method
+// encode/decode functions with the shim package's registry. Combined with
+// shim.Lookup, this lets an RPC dispatcher route a (service, method) call
+// straight to typed codecs without reflect or an interface{} round trip.
+//
+// Generated declarations are routed to a file named after the service
+// (see GroupTypes for the grouping of everything else).
+func (g *Generator) DeclareService(service *compile.ServiceSpec) error {
+	registerFuncs := make([]string, 0, len(service.Functions))
+
+	// Line is left at its zero value: compile's ServiceSpec/FunctionSpec
+	// don't retain a source line past compilation (it only lives on the
+	// AST), so there is nothing truthful to put here until compile is
+	// extended to carry one. ThriftFile, on the other hand, is a method
+	// (the same accessor pattern as TypeSpec.ThriftFile()), not a field.
+	for _, function := range service.Functions {
+		data := serviceMethodData{Service: service, Function: function}
+		pos := &DeclPosition{
+			File:   service.ThriftFile(),
+			Symbol: service.Name + "." + function.Name,
+		}
+		if err := g.DeclareFromTemplateName(service.Name, pos, "service-shim", shimTemplate, data); err != nil {
+			return fmt.Errorf(
+				"could not declare shim for %s.%s: %v", service.Name, function.Name, err,
+			)
+		}
+		registerFuncs = append(registerFuncs, "register"+goCase(service.Name)+goCase(function.Name))
+	}
+
+	initData := serviceInitData{RegisterFuncs: registerFuncs}
+	pos := &DeclPosition{File: service.ThriftFile(), Symbol: service.Name}
+	if err := g.DeclareFromTemplateName(service.Name, pos, "service-init", serviceInitTemplate, initData); err != nil {
+		return fmt.Errorf("could not declare init for service %s: %v", service.Name, err)
+	}
+
+	return nil
+}
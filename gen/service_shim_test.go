@@ -0,0 +1,64 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/thriftrw/thriftrw-go/compile"
+)
+
+func TestDeclareServiceWithMultipleMethods(t *testing.T) {
+	service := &compile.ServiceSpec{
+		Name: "Foo",
+		File: "foo.thrift",
+		Functions: map[string]*compile.FunctionSpec{
+			"bar": {Name: "bar", ResultSpec: &compile.ResultSpec{}},
+			"baz": {Name: "baz", ResultSpec: &compile.ResultSpec{}},
+		},
+	}
+
+	g := NewGenerator()
+	if err := g.DeclareService(service); err != nil {
+		t.Fatalf("DeclareService on a 2-method service = %v, want nil", err)
+	}
+
+	files, err := g.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, ok := files["Foo"]
+	if !ok {
+		t.Fatal(`Files()["Foo"] missing; DeclareService should route decls into a file named after the service`)
+	}
+
+	inits := 0
+	for _, decl := range f.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == "init" {
+			inits++
+		}
+	}
+	if inits != 1 {
+		t.Fatalf("got %d top-level func init() decls for a 2-method service, want exactly 1 combined init", inits)
+	}
+}
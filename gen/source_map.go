@@ -0,0 +1,147 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+)
+
+// DeclPosition identifies the Thrift source location that a generated
+// declaration came from, so that generated code can point back at it.
+type DeclPosition struct {
+	// File is the path to the .thrift file, as the compiler saw it.
+	File string
+
+	// Line is the 1-indexed line in File, or 0 when the line is not known.
+	Line int
+
+	// Symbol is the dotted Thrift name the declaration came from, e.g.
+	// "MyStruct.myField" or "MyService.myMethod".
+	Symbol string
+}
+
+func (p *DeclPosition) String() string {
+	return fmt.Sprintf("%s:%d %s", p.File, p.Line, p.Symbol)
+}
+
+// setDeclDoc attaches a "// Generated from <file>:<line> <Symbol>" leading
+// comment to decl, the same technique protoc-gen-go uses to keep generated
+// declarations traceable to their source. It is a no-op if pos is nil.
+func setDeclDoc(decl ast.Decl, pos *DeclPosition) {
+	if pos == nil {
+		return
+	}
+
+	doc := &ast.CommentGroup{
+		List: []*ast.Comment{{Text: "// Generated from " + pos.String()}},
+	}
+
+	switch d := decl.(type) {
+	case *ast.GenDecl:
+		d.Doc = doc
+	case *ast.FuncDecl:
+		d.Doc = doc
+	}
+}
+
+// rawSection is the unformatted text rendered by a single
+// DeclareFromTemplate call, kept around so that WriteRaw can emit it
+// verbatim with a preceding //line directive instead of re-printing the
+// parsed AST through go/format.
+type rawSection struct {
+	pos  *DeclPosition
+	text string
+}
+
+// WriteRaw renders every file in the Generator to "<dir>/<group>.go"
+// without running it through go/format: each declaration is written
+// exactly as the template produced it, preceded by a compiler //line
+// directive (https://golang.org/cmd/compile/#hdr-Compiler_Directives)
+// pointing back at the Thrift source that produced it where one is known.
+//
+// Unlike WriteFiles, a compile error anywhere in the emitted Go points at
+// the offending .thrift file and line instead of an unreadable blob of
+// generated code, and a parse failure in one declaration does not
+// prevent the rest of the file from being written.
+func (g *Generator) WriteRaw(dir string) error {
+	for group, fb := range g.files {
+		path := filepath.Join(dir, group+".go")
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+
+		err = writeRawFile(f, g.PackageName, fb)
+		closeErr := f.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return nil
+}
+
+func writeRawFile(w *os.File, packageName string, fb *fileBuilder) error {
+	if _, err := fmt.Fprintf(w, "package %s\n\n", packageName); err != nil {
+		return err
+	}
+
+	if importDecl := fb.importDecl(); importDecl != nil {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, token.NewFileSet(), importDecl); err != nil {
+			return fmt.Errorf("could not render imports: %v", err)
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.WriteString("\n\n"); err != nil {
+			return err
+		}
+	}
+
+	for _, section := range fb.raw {
+		if section.pos != nil {
+			if _, err := fmt.Fprintf(w, "// Generated from %s\n", section.pos); err != nil {
+				return err
+			}
+			if section.pos.Line > 0 {
+				if _, err := fmt.Fprintf(w, "//line %s:%d\n", section.pos.File, section.pos.Line); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := w.WriteString(section.text); err != nil {
+			return err
+		}
+		if _, err := w.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
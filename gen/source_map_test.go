@@ -0,0 +1,66 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thriftrw/thriftrw-go/compile"
+)
+
+// TestDeclareServicePopulatesFileAndSymbol guards DeclareService's use of
+// service.ThriftFile() (a method, not a field) to build each method shim's
+// DeclPosition. Line is intentionally left at its zero value: neither
+// compile.ServiceSpec nor compile.FunctionSpec retain a source line past
+// compilation, so there is nothing truthful to put there yet.
+func TestDeclareServicePopulatesFileAndSymbol(t *testing.T) {
+	service := &compile.ServiceSpec{
+		Name: "Foo",
+		File: "foo.thrift",
+		Functions: map[string]*compile.FunctionSpec{
+			"bar": {Name: "bar", ResultSpec: &compile.ResultSpec{}},
+		},
+	}
+
+	g := NewGenerator()
+	if err := g.DeclareService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	fb := g.files["Foo"]
+	found := false
+	for _, section := range fb.raw {
+		if section.pos == nil || section.pos.Symbol != "Foo.bar" {
+			continue
+		}
+		found = true
+		if section.pos.File != "foo.thrift" {
+			t.Fatalf("DeclPosition for Foo.bar has File %q, want %q", section.pos.File, "foo.thrift")
+		}
+		if !strings.Contains(section.pos.String(), "foo.thrift:0 Foo.bar") {
+			t.Fatalf("DeclPosition.String() = %q, want it to mention foo.thrift:0 Foo.bar", section.pos.String())
+		}
+	}
+	if !found {
+		t.Fatal("no raw section recorded for Foo.bar")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// TemplateSet holds the named templates the Generator renders from, and
+// lets Options.TemplateOverrides replace any of them by name without
+// forking this module. The templates built into this package are
+// "fastcodec", "service-shim" and "service-init"; no other generated code
+// is rendered through a named template, so it cannot be overridden.
+type TemplateSet struct {
+	builtins  map[string]string
+	overrides map[string]string
+}
+
+// newTemplateSet builds a TemplateSet whose overrides are loaded from dir.
+// dir may be empty, in which case no template is ever overridden.
+func newTemplateSet(dir string) (*TemplateSet, error) {
+	ts := &TemplateSet{builtins: make(map[string]string)}
+	if dir == "" {
+		return ts, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list template overrides in %q: %v", dir, err)
+	}
+
+	ts.overrides = make(map[string]string, len(matches))
+	for _, path := range matches {
+		name := filepath.Base(path)
+		name = name[:len(name)-len(filepath.Ext(name))]
+
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read template override %q: %v", path, err)
+		}
+		ts.overrides[name] = string(bs)
+	}
+	return ts, nil
+}
+
+// Register adds a built-in template under the given name. It is a no-op,
+// in terms of what Get returns, for any name that already has a directory
+// override.
+func (ts *TemplateSet) Register(name, text string) {
+	ts.builtins[name] = text
+}
+
+// Get returns the template text that should be rendered for the given
+// name: the user-supplied override if one was loaded for that name,
+// otherwise the built-in template registered under that name.
+func (ts *TemplateSet) Get(name string) (string, error) {
+	if text, ok := ts.overrides[name]; ok {
+		return text, nil
+	}
+	if text, ok := ts.builtins[name]; ok {
+		return text, nil
+	}
+	return "", fmt.Errorf("no template named %q has been registered", name)
+}
+
+// FuncMap returns the functions available to templates rendered through
+// TextTemplate, keyed by the name templates call them with. It is exposed
+// publicly so that Options.TemplateOverrides can write templates that call
+// toWire, fromWire, typeReference, and the rest of the built-in helpers.
+func (g *Generator) FuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"goCase":          goCase,
+		"import":          g.Import,
+		"defName":         typeDeclName,
+		"newVar":          g.namespace.Child().NewName,
+		"toWire":          g.toWire,
+		"fromWire":        g.fromWire,
+		"typeName":        typeName,
+		"typeCode":        g.typeCode,
+		"typeReference":   typeReference,
+		"isStructType":    isStructType,
+		"isReferenceType": isReferenceType,
+		"hasFastCodec":    g.hasFastCodec,
+		"encodeField":     g.encodeField,
+		"decodeField":     g.decodeField,
+
+		"Required": func() fieldRequired { return Required },
+		"Optional": func() fieldRequired { return Optional },
+		"required": func(b bool) fieldRequired {
+			if b {
+				return Required
+			}
+			return Optional
+		},
+	}
+}
+
+// TemplateSet returns this Generator's TemplateSet, loading overrides from
+// Options.TemplateOverrides (if set) the first time it is called.
+func (g *Generator) TemplateSet() (*TemplateSet, error) {
+	if g.templates == nil {
+		ts, err := newTemplateSet(g.Options.TemplateOverrides)
+		if err != nil {
+			return nil, err
+		}
+		g.templates = ts
+	}
+	return g.templates, nil
+}
+
+// DeclareFromTemplateName registers builtin under name in this Generator's
+// TemplateSet (a repeat registration under the same name is a harmless
+// no-op as long as callers always pass the same constant), then renders
+// whichever of builtin or a matching file in Options.TemplateOverrides
+// wins, and declares it exactly like DeclareFromTemplate.
+//
+// This is how every template built into this package should be rendered:
+// going through DeclareFromTemplate directly, as DeclareService's shim
+// template used to, bypasses Options.TemplateOverrides entirely.
+func (g *Generator) DeclareFromTemplateName(group string, pos *DeclPosition, name string, builtin string, data interface{}) error {
+	ts, err := g.TemplateSet()
+	if err != nil {
+		return err
+	}
+	ts.Register(name, builtin)
+
+	text, err := ts.Get(name)
+	if err != nil {
+		return err
+	}
+	return g.DeclareFromTemplate(group, pos, text, data)
+}
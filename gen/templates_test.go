@@ -0,0 +1,97 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"bytes"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, g *Generator, group string) string {
+	t.Helper()
+
+	files, err := g.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, ok := files[group]
+	if !ok {
+		t.Fatalf("Files()[%q] missing", group)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), f); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDeclareFromTemplateNameUsesBuiltinByDefault(t *testing.T) {
+	g := NewGenerator()
+	data := struct{ Name string }{Name: "Greeting"}
+
+	err := g.DeclareFromTemplateName(GroupTypes, nil, "greeting", "type <.Name> int32\n", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := render(t, g, GroupTypes)
+	if !strings.Contains(got, "type Greeting int32") {
+		t.Fatalf("generated output = %q, want the builtin template's \"int32\" type", got)
+	}
+}
+
+func TestDeclareFromTemplateNameAppliesOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thriftrw-template-override")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	override := "type <.Name> string\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "greeting.tmpl"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGenerator()
+	g.Options.TemplateOverrides = dir
+
+	data := struct{ Name string }{Name: "Greeting"}
+	err = g.DeclareFromTemplateName(GroupTypes, nil, "greeting", "type <.Name> int32\n", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := render(t, g, GroupTypes)
+	if !strings.Contains(got, "type Greeting string") {
+		t.Fatalf("generated output = %q, want the override's \"string\" type to win", got)
+	}
+	if strings.Contains(got, "int32") {
+		t.Fatalf("generated output = %q, want the builtin template not to have been used once an override exists", got)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package shim holds a global registry of per-method encode/decode
+// functions generated by gen.Generator.DeclareService. It lets an RPC
+// handler dispatch a (service, method) pair straight to the typed
+// functions that generated code registered in its init(), instead of
+// looking up a method by name through reflection.
+package shim
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/thriftrw/thriftrw-go/wire"
+)
+
+// EncodeFunc converts a generated Args or Result struct into its wire
+// representation. The argument is always the concrete generated type for
+// the method being registered; it is interface{} only because the
+// registry holds functions for many different methods.
+type EncodeFunc func(interface{}) (wire.Value, error)
+
+// DecodeFunc is the inverse of EncodeFunc: it reads a wire.Value back into
+// the concrete generated Args or Result type for the method being
+// registered.
+type DecodeFunc func(wire.Value) (interface{}, error)
+
+// MethodCodec bundles the four functions generated for a single Thrift
+// method.
+type MethodCodec struct {
+	EncodeArgs   EncodeFunc
+	DecodeArgs   DecodeFunc
+	EncodeResult EncodeFunc
+	DecodeResult DecodeFunc
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]map[string]*MethodCodec)
+)
+
+// RegisterMethod records the codec functions generated for the given
+// service and method. Generated code calls this from an init() function,
+// so registration happens once, at program start, before any dispatch.
+//
+// RegisterMethod panics if the same (service, method) pair is registered
+// twice, since that can only happen because of a codegen or build bug.
+func RegisterMethod(service, method string, encodeArgs EncodeFunc, decodeArgs DecodeFunc, encodeResult EncodeFunc, decodeResult DecodeFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	methods, ok := registry[service]
+	if !ok {
+		methods = make(map[string]*MethodCodec)
+		registry[service] = methods
+	}
+
+	if _, ok := methods[method]; ok {
+		panic(fmt.Sprintf("thriftrw/shim: method %q.%q is already registered", service, method))
+	}
+
+	methods[method] = &MethodCodec{
+		EncodeArgs:   encodeArgs,
+		DecodeArgs:   decodeArgs,
+		EncodeResult: encodeResult,
+		DecodeResult: decodeResult,
+	}
+}
+
+// Lookup returns the codec registered for the given service and method,
+// and whether one was found.
+func Lookup(service, method string) (*MethodCodec, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	methods, ok := registry[service]
+	if !ok {
+		return nil, false
+	}
+	codec, ok := methods[method]
+	return codec, ok
+}